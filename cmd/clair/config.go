@@ -17,15 +17,19 @@ package main
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fernet/fernet-go"
+	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/coreos/clair"
 	"github.com/coreos/clair/api"
 	"github.com/coreos/clair/database"
 	"github.com/coreos/clair/ext/notification"
+	"github.com/coreos/clair/pkg/logging"
+	"github.com/coreos/clair/pkg/secrets"
 	"github.com/spf13/viper"
 )
 
@@ -33,6 +37,33 @@ import (
 // configuration file is not loaded properly
 var ErrDatasourceNotLoaded = errors.New("could not load configuration: no database source specified")
 
+// envOverrides lists every config key that may be supplied via a CLAIR_*
+// environment variable. Viper only resolves env vars for keys it already
+// knows about (from the config file, a default, or an explicit bind), so
+// each overridable key must be registered here even though AutomaticEnv is
+// enabled.
+var envOverrides = []string{
+	"clair.database.type",
+	"clair.database.options.source",
+	"clair.database.options.cachesize",
+	"clair.database.options.paginationkey",
+	"clair.updater.interval",
+	"clair.updater.enabledupdaters",
+	"clair.notifier.attempts",
+	"clair.notifier.renotifyinterval",
+	"clair.api.port",
+	"clair.api.healthport",
+	"clair.api.timeout",
+	"clair.api.certfile",
+	"clair.api.keyfile",
+	"clair.api.cafile",
+	"clair.log.level",
+	"clair.log.output",
+	"clair.log.format",
+	"clair.log.pkglevels",
+	"clair.startup.deadline",
+}
+
 // File represents a YAML configuration file that namespaces all Clair
 // configuration under the top-level "clair" key.
 type File struct {
@@ -45,6 +76,18 @@ type Config struct {
 	Updater  *clair.UpdaterConfig
 	Notifier *notification.Config
 	API      *api.Config
+	Log      logging.Config
+	Startup  StartupConfig
+}
+
+// StartupConfig controls how long Clair waits for its dependencies (the
+// database, and any notifier endpoints) to become reachable before giving
+// up.
+type StartupConfig struct {
+	// Deadline is the overall time budget for waitForDependencies. Once it
+	// elapses, Clair exits rather than serving traffic against a dependency
+	// that may never come up.
+	Deadline time.Duration
 }
 
 // clairConfig holds the Viper configuration for Clair
@@ -68,110 +111,207 @@ func DefaultConfig() Config {
 			Attempts:         5,
 			RenotifyInterval: 2 * time.Hour,
 		},
+		Log: logging.Config{
+			Level:  "info",
+			Output: "stderr",
+		},
+		Startup: StartupConfig{
+			Deadline: 5 * time.Minute,
+		},
 	}
 }
 
-// LoadConfig is a shortcut to open a file, read it, and generate a Config.
-//
-// It supports relative and absolute paths. Given "", it returns DefaultConfig.
-func LoadConfig(path string) (config *Config, err error) {
+// contains reports whether s is present in ss.
+func contains(ss []string, s string) bool {
+	for _, candidate := range ss {
+		if candidate == s {
+			return true
+		}
+	}
+	return false
+}
 
-	if clairConfig == nil {
-		clairConfig = viper.New()
-		clairConfig.SetConfigName("clair")
-		clairConfig.SetConfigFile(path)
+// Validate sanity-checks a loaded Config and returns a descriptive error for
+// the first problem it finds, or nil if the configuration is usable.
+func (c *Config) Validate() error {
+	if c.Database.Type == "" {
+		return errors.New("could not load configuration: no database type specified")
+	}
+	// database.RegisteredDrivers lists the drivers that actually registered
+	// themselves via database.Register, so this check can't drift from what
+	// the binary was built with the way a hand-maintained allowlist would.
+	if !contains(database.RegisteredDrivers(), c.Database.Type) {
+		return fmt.Errorf("unknown database type %q", c.Database.Type)
+	}
 
-		err = clairConfig.ReadInConfig()
+	source, _ := c.Database.Options["source"].(string)
+	if source == "" {
+		return ErrDatasourceNotLoaded
 	}
-	// Any config variable can be read from environment variables prefixed with "CLAIR_"
-	clairConfig.SetEnvPrefix("clair")
-	clairConfig.AutomaticEnv()
 
-	// Set values as loaded by Viper. I think this is short term fix - probably better to use the viper code in the rest of Clair...
-	var cfgFile File
-	cfgFile.Clair = DefaultConfig()
-	cfgFile.Clair.Database.Options = map[string]interface{}{}
+	if key, ok := c.Database.Options["paginationkey"].(string); ok && key != "" {
+		if _, err := fernet.DecodeKey(key); err != nil {
+			return errors.New("invalid pagination key: must be 32-byte URL-safe base64")
+		}
+	}
 
-	if clairConfig.IsSet("clair.database.type") {
-		cfgFile.Clair.Database.Type = clairConfig.GetString("clair.database.type")
+	if c.Updater != nil && c.Updater.Interval <= 0 {
+		return fmt.Errorf("invalid updater interval: %v", c.Updater.Interval)
 	}
-	if clairConfig.IsSet("clair.database.options.source") {
-		cfgFile.Clair.Database.Options["source"] = clairConfig.GetString("clair.database.options.source")
+
+	if c.Notifier != nil {
+		if c.Notifier.RenotifyInterval <= 0 {
+			return fmt.Errorf("invalid notifier renotify interval: %v", c.Notifier.RenotifyInterval)
+		}
+		for name := range c.Notifier.Params {
+			if !contains(notification.RegisteredSenders(), name) {
+				return fmt.Errorf("unknown notifier type %q", name)
+			}
+		}
+	}
+
+	if c.API == nil {
+		return errors.New("could not load configuration: no api section specified")
 	}
-	if clairConfig.IsSet("clair.database.options.cachesize") {
-		cfgFile.Clair.Database.Options["cachesize"] = clairConfig.GetString("clair.database.options.cachesize")
+	if c.API.Port == c.API.HealthPort {
+		return fmt.Errorf("api port and health port must differ, both are %d", c.API.Port)
 	}
-	if clairConfig.IsSet("clair.database.options.paginationkey") {
-		cfgFile.Clair.Database.Options["paginationkey"] = clairConfig.GetString("clair.database.options.paginationkey")
+	if c.API.Timeout <= 0 {
+		return fmt.Errorf("invalid api timeout: %v", c.API.Timeout)
 	}
-	// if clairConfig.IsSet("clair.database.api.addr") {
-	// 	cfgFile.Clair.API.Addr = clairConfig.GetString("clair.database.api.addr")
-	// }
-	if clairConfig.IsSet("clair.database.api.healthaddr") {
-		cfgFile.Clair.API.HealthPort = clairConfig.GetInt("clair.database.api.healthport")
+
+	if c.Startup.Deadline <= 0 {
+		return fmt.Errorf("invalid startup deadline: %v", c.Startup.Deadline)
 	}
-	if clairConfig.IsSet("clair.database.api.timeout") {
-		cfgFile.Clair.API.Timeout, err = time.ParseDuration(clairConfig.GetString("clair.database.api.timeout"))
+
+	return nil
+}
+
+// resolveSecrets expands secret://<provider>/<ref> references found in
+// database options, TLS key material paths, and notifier params, so
+// deployments can keep credentials out of the config file and container
+// image. See pkg/secrets for the supported providers.
+func resolveSecrets(config *Config) error {
+	for key, value := range config.Database.Options {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		resolved, err := secrets.Expand(s)
 		if err != nil {
-			return
+			return err
 		}
+		config.Database.Options[key] = resolved
 	}
-	// database.api.servername is in sample config, but looks like not referenced in code?
-	if clairConfig.IsSet("clair.database.api.cafile") {
-		cfgFile.Clair.API.CAFile = clairConfig.GetString("clair.database.api.cafile")
+
+	if config.API != nil {
+		// These are filesystem paths, not the certificate/key content
+		// itself, so only path-yielding providers (env) are allowed here.
+		// A content-yielding reference (file, vault) belongs on a field
+		// that's used as the content directly, not handed to
+		// tls.LoadX509KeyPair as a path.
+		for _, field := range []*string{&config.API.CAFile, &config.API.CertFile, &config.API.KeyFile} {
+			resolved, err := secrets.ExpandPath(*field)
+			if err != nil {
+				return err
+			}
+			*field = resolved
+		}
 	}
-	if clairConfig.IsSet("clair.database.api.keyfile") {
-		cfgFile.Clair.API.KeyFile = clairConfig.GetString("clair.database.api.keyfile")
+
+	if config.Notifier != nil {
+		for key, value := range config.Notifier.Params {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			resolved, err := secrets.Expand(s)
+			if err != nil {
+				return err
+			}
+			config.Notifier.Params[key] = resolved
+		}
 	}
-	if clairConfig.IsSet("clair.database.api.certfile") {
-		cfgFile.Clair.API.CertFile = clairConfig.GetString("clair.database.api.certfile")
+
+	return nil
+}
+
+// LoadConfig is a shortcut to open a file, read it, and generate a Config.
+//
+// It supports relative and absolute paths. The path given on the first call
+// is kept; later calls re-read that same file. Given "", no file is found and
+// LoadConfig returns the error from ReadInConfig.
+func LoadConfig(path string) (config *Config, err error) {
+	if clairConfig == nil {
+		clairConfig = viper.New()
+		clairConfig.SetConfigName("clair")
+		clairConfig.SetConfigFile(path)
 	}
-	// if clairConfig.IsSet("clair.database.worker.namespace_detectors") {
-	// 	cfgFile.Clair.Worker.EnabledDetectors = clairConfig.GetStringSlice("clair.database.worker.namespace_detectors")
-	// }
-	// if clairConfig.IsSet("clair.database.worker.feature_listers") {
-	// 	cfgFile.Clair.Worker.EnabledListers = clairConfig.GetStringSlice("clair.database.worker.feature_listers")
-	// }
-	if clairConfig.IsSet("clair.database.updater.interval") {
-		cfgFile.Clair.Updater.Interval, err = time.ParseDuration(clairConfig.GetString("clair.database.updater.interval"))
-		if err != nil {
+	// Re-read on every call (not just the first) so that a reload triggered
+	// by ConfigWatcher picks up on-disk changes instead of replaying the
+	// values viper happened to cache at startup.
+	if err = clairConfig.ReadInConfig(); err != nil {
+		return
+	}
+
+	// Every key in envOverrides can be read from an environment variable
+	// prefixed with "CLAIR_", with nested keys joined by underscores, e.g.
+	// CLAIR_DATABASE_OPTIONS_SOURCE or CLAIR_API_TIMEOUT. Keys not listed
+	// there (such as individual notifier params) are not read from
+	// variables named this way.
+	clairConfig.SetEnvPrefix("clair")
+	clairConfig.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	clairConfig.AutomaticEnv()
+	// BindEnv's single-arg form derives the var name as
+	// ToUpper(prefix + "_" + key), which would double the "clair" prefix
+	// already present in each envOverrides entry (e.g.
+	// "clair.database.options.source" would bind to
+	// CLAIR_CLAIR_DATABASE_OPTIONS_SOURCE instead of
+	// CLAIR_DATABASE_OPTIONS_SOURCE). Strip the leading "clair." ourselves
+	// and bind the two-arg form so the var name matches what's documented.
+	dotToUnderscore := strings.NewReplacer(".", "_")
+	for _, key := range envOverrides {
+		envVar := "CLAIR_" + strings.ToUpper(dotToUnderscore.Replace(strings.TrimPrefix(key, "clair.")))
+		if err = clairConfig.BindEnv(key, envVar); err != nil {
 			return
 		}
 	}
-	// if clairConfig.IsSet("clair.database.updater.enabledupdaters") {
-	// 	cfgFile.Clair.Updater.EnabledUpdaters = clairConfig.GetStringSlice("clair.database.updater.enabledupdaters")
-	// }
-	if clairConfig.IsSet("clair.database.notifier.attempts") {
-		cfgFile.Clair.Notifier.Attempts = clairConfig.GetInt("clair.database.updater.attempts")
+	// CLAIR_LOG_PKG_LEVELS is the documented env var for per-package level
+	// overrides; it doesn't follow the mechanical dot-to-underscore mapping
+	// above, so it needs its own explicit binding.
+	if err = clairConfig.BindEnv("clair.log.pkglevels", "CLAIR_LOG_PKG_LEVELS"); err != nil {
+		return
 	}
-	if clairConfig.IsSet("clair.database.notifier.renotifyinterval") {
-		cfgFile.Clair.Notifier.RenotifyInterval, err = time.ParseDuration(clairConfig.GetString("clair.database.updater.renotifyinterval"))
-		if err != nil {
-			return
-		}
+
+	var cfgFile File
+	cfgFile.Clair = DefaultConfig()
+	cfgFile.Clair.Database.Options = map[string]interface{}{}
+
+	decodeHook := mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+	if err = clairConfig.Unmarshal(&cfgFile, viper.DecodeHook(decodeHook)); err != nil {
+		return
 	}
-	if clairConfig.IsSet("clair.database.notifier.http") {
-		config.Notifier.Params["http"] = clairConfig.GetString("clair.database.updater.http")
-		// debug - just checking that this works...
-		fmt.Printf("clair.database.notifier.http: %s", clairConfig.GetString("clair.database.updater.http"))
+
+	if err = resolveSecrets(&cfgFile.Clair); err != nil {
+		return
 	}
 
 	// Generate a pagination key if none is provided.
-	if !clairConfig.IsSet("clair.database.options.paginationkey") {
+	if key, _ := cfgFile.Clair.Database.Options["paginationkey"].(string); key == "" {
 		log.Warn("pagination key is empty, generating...")
-		var key fernet.Key
-		if err = key.Generate(); err != nil {
-			return
-		}
-		cfgFile.Clair.Database.Options["paginationkey"] = key.Encode()
-	} else {
-		config.Database.Options["paginationkey"] = clairConfig.GetString("paginationkey")
-		_, err = fernet.DecodeKey(clairConfig.GetString("paginationkey"))
-		if err != nil {
-			err = errors.New("Invalid Pagination key; must be 32-bit URL-safe base64")
+		var fkey fernet.Key
+		if err = fkey.Generate(); err != nil {
 			return
 		}
+		cfgFile.Clair.Database.Options["paginationkey"] = fkey.Encode()
+	} else if _, err = fernet.DecodeKey(key); err != nil {
+		err = errors.New("invalid pagination key: must be 32-byte URL-safe base64")
+		return
 	}
+
 	config = &cfgFile.Clair
 	return
 }
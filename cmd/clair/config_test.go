@@ -0,0 +1,156 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	validKey := "aCcl-m0zOHQ6OaYG6pr7aM1PHo-QQLLSX6g-w8gqwnM="
+
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{
+			name:    "valid config",
+			mutate:  func(c *Config) {},
+			wantErr: "",
+		},
+		{
+			name: "missing database type",
+			mutate: func(c *Config) {
+				c.Database.Type = ""
+			},
+			wantErr: "no database type specified",
+		},
+		{
+			name: "unknown database type",
+			mutate: func(c *Config) {
+				c.Database.Type = "mysql"
+			},
+			wantErr: `unknown database type "mysql"`,
+		},
+		{
+			name: "missing datasource",
+			mutate: func(c *Config) {
+				delete(c.Database.Options, "source")
+			},
+			wantErr: ErrDatasourceNotLoaded.Error(),
+		},
+		{
+			name: "unknown notifier type",
+			mutate: func(c *Config) {
+				c.Notifier.Params = map[string]interface{}{"slack": "https://example.com/webhook"}
+			},
+			wantErr: `unknown notifier type "slack"`,
+		},
+		{
+			name: "invalid pagination key",
+			mutate: func(c *Config) {
+				c.Database.Options["paginationkey"] = "not-a-valid-key"
+			},
+			wantErr: "invalid pagination key",
+		},
+		{
+			name: "non-positive updater interval",
+			mutate: func(c *Config) {
+				c.Updater.Interval = 0
+			},
+			wantErr: "invalid updater interval",
+		},
+		{
+			name: "non-positive api timeout",
+			mutate: func(c *Config) {
+				c.API.Timeout = 0
+			},
+			wantErr: "invalid api timeout",
+		},
+		{
+			name: "port collision",
+			mutate: func(c *Config) {
+				c.API.HealthPort = c.API.Port
+			},
+			wantErr: "must differ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Database.Options = map[string]interface{}{
+				"source":        "host=db",
+				"paginationkey": validKey,
+			}
+			test.mutate(&cfg)
+
+			err := cfg.Validate()
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateDurationDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Database.Options = map[string]interface{}{"source": "host=db"}
+
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, time.Hour, cfg.Updater.Interval)
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clair.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+clair:
+  database:
+    type: pgsql
+    options:
+      source: host=configured-db
+      paginationkey: aCcl-m0zOHQ6OaYG6pr7aM1PHo-QQLLSX6g-w8gqwnM=
+  api:
+    port: 6060
+    healthport: 6061
+    timeout: 900s
+`), 0644))
+
+	require.NoError(t, os.Setenv("CLAIR_DATABASE_OPTIONS_SOURCE", "host=env-db"))
+	defer os.Unsetenv("CLAIR_DATABASE_OPTIONS_SOURCE")
+	require.NoError(t, os.Setenv("CLAIR_API_TIMEOUT", "30s"))
+	defer os.Unsetenv("CLAIR_API_TIMEOUT")
+
+	clairConfig = nil
+	defer func() { clairConfig = nil }()
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "host=env-db", cfg.Database.Options["source"])
+	assert.Equal(t, 30*time.Second, cfg.API.Timeout)
+}
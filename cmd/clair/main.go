@@ -0,0 +1,129 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main is the entrypoint for the Clair binary: it loads
+// configuration, wires up the API, updater and notifier, and blocks until a
+// termination signal is received.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/coreos/clair"
+	"github.com/coreos/clair/api"
+	"github.com/coreos/clair/ext/notification"
+	"github.com/coreos/clair/pkg/logging"
+	"github.com/coreos/clair/pkg/tlsreload"
+)
+
+// Flags holds the values of Clair's command-line flags.
+type Flags struct {
+	ConfigPath     string
+	CPUProfilePath string
+	LogLevel       string
+	LogOutput      string
+	LogFormat      string
+}
+
+func main() {
+	var flags Flags
+	flag.StringVar(&flags.ConfigPath, "config", "/etc/clair/config.yaml", "Load configuration from the specified file.")
+	flag.StringVar(&flags.CPUProfilePath, "cpu-profile", "", "Write a CPU profile to the specified file before exiting.")
+	flag.StringVar(&flags.LogLevel, "log-level", "", "Define the logging level. Overrides the config file's log.level.")
+	flag.StringVar(&flags.LogOutput, "log-output", "", "Define where logs are written: stdout, stderr, file:/path, or journald. Overrides the config file's log.output.")
+	flag.StringVar(&flags.LogFormat, "log-format", "", "Define the log line format: text, json, or pretty. Overrides the config file's log.format.")
+	flag.Parse()
+
+	config, err := LoadConfig(flags.ConfigPath)
+	if err != nil {
+		log.WithError(err).Fatal("failed to load configuration")
+	}
+
+	if flags.LogLevel != "" {
+		config.Log.Level = flags.LogLevel
+	}
+	if flags.LogOutput != "" {
+		config.Log.Output = flags.LogOutput
+	}
+	if flags.LogFormat != "" {
+		config.Log.Format = flags.LogFormat
+	}
+	if err := logging.Configure(config.Log); err != nil {
+		log.WithError(err).Fatal("failed to configure logging")
+	}
+	logger := logging.For("main")
+
+	if err := config.Validate(); err != nil {
+		logger.WithError(err).Fatal("invalid configuration")
+	}
+
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+
+	db, err := waitForDependencies(config, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("dependencies never became ready")
+	}
+	defer db.Close()
+
+	// Load the TLS keypair (if configured) before starting the API so its
+	// GetCertificate callback, not a static certificate, backs the listener
+	// from the very first handshake. ConfigWatcher reloads the same
+	// *tlsreload.Keypair in place on SIGHUP.
+	var tlsConfig *tls.Config
+	var keypair *tlsreload.Keypair
+	if config.API.CertFile != "" && config.API.KeyFile != "" {
+		keypair, err = tlsreload.New(config.API.CertFile, config.API.KeyFile)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load TLS certificate")
+		}
+		tlsConfig = &tls.Config{GetCertificate: keypair.GetCertificate}
+	}
+
+	updater := clair.NewUpdater(config.Updater, db, logging.For("updater"))
+	wg.Add(1)
+	go updater.Run(stopCh, &wg)
+
+	notifier := notification.NewNotifier(config.Notifier, db, logging.For("notifier"))
+	wg.Add(1)
+	go notifier.Run(stopCh, &wg)
+
+	wg.Add(1)
+	go api.Run(config.API, db, tlsConfig, stopCh, &wg, logging.For("api"))
+
+	watcher := NewConfigWatcher(flags.ConfigPath, config)
+	watcher.Updater = updater
+	watcher.Notifier = notifier
+	watcher.TLS = keypair
+	watcher.Watch(stopCh)
+
+	waitForSignals(syscall.SIGINT, syscall.SIGTERM)
+	logger.Info("clair: received interrupt, shutting down")
+	close(stopCh)
+	wg.Wait()
+}
+
+// waitForSignals blocks until one of the given signals is received.
+func waitForSignals(signals ...os.Signal) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, signals...)
+	<-interrupts
+}
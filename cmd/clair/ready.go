@@ -0,0 +1,177 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/coreos/clair/database"
+	"github.com/coreos/clair/ext/notification"
+	"github.com/coreos/clair/pkg/logging"
+)
+
+const (
+	backoffInitial = 1 * time.Second
+	backoffFactor  = 2
+	backoffCap     = 30 * time.Second
+
+	notifierProbeTimeout = 5 * time.Second
+)
+
+// dependency is a single thing waitForDependencies must see come up before
+// Clair starts serving the main API.
+type dependency struct {
+	name  string
+	check func() error
+}
+
+// readinessHandler serves /health on the configured HealthPort while
+// waitForDependencies is still polling, so orchestrators doing a rolling
+// restart see 503s instead of connection refused. Once every dependency is
+// ready it reports 200 and gets out of the way.
+type readinessHandler struct {
+	mu       sync.RWMutex
+	ready    bool
+	notReady map[string]string
+}
+
+func newReadinessHandler() *readinessHandler {
+	return &readinessHandler{notReady: map[string]string{}}
+}
+
+func (h *readinessHandler) update(notReady map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.notReady = notReady
+	h.ready = len(notReady) == 0
+}
+
+func (h *readinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	body := map[string]interface{}{"ready": h.ready}
+	if !h.ready {
+		body["notReady"] = h.notReady
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// notifierDependencies returns a dependency for the webhook endpoint given as
+// a string under cfg.Params["http"], so waitForDependencies also blocks on a
+// notifier webhook that isn't reachable yet (e.g. a sidecar that starts a few
+// seconds after Clair). Other entries in cfg.Params are not probed.
+func notifierDependencies(cfg *notification.Config) []dependency {
+	if cfg == nil {
+		return nil
+	}
+
+	endpoint, ok := cfg.Params["http"].(string)
+	if !ok || endpoint == "" {
+		return nil
+	}
+
+	client := &http.Client{Timeout: notifierProbeTimeout}
+	return []dependency{{
+		name: "notifier:http",
+		check: func() error {
+			resp, err := client.Head(endpoint)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return fmt.Errorf("unexpected status %s", resp.Status)
+			}
+			return nil
+		},
+	}}
+}
+
+// waitForDependencies blocks, serving /health on config.API.HealthPort,
+// until the configured database and any notifier endpoints are reachable or
+// config.Startup.Deadline elapses. On success it returns the opened
+// database and the health listener is closed so api.Run can bind its own
+// health endpoint on the same port.
+func waitForDependencies(config *Config, logger *log.Logger) (database.Datastore, error) {
+	health := newReadinessHandler()
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.API.HealthPort))
+	if err != nil {
+		return nil, fmt.Errorf("waitForDependencies: could not bind health port: %v", err)
+	}
+	server := &http.Server{Handler: health}
+	go server.Serve(listener)
+	defer listener.Close()
+
+	deadline := time.Now().Add(config.Startup.Deadline)
+	interval := backoffInitial
+
+	var db database.Datastore
+	for {
+		deps := []dependency{{
+			name: "database",
+			check: func() error {
+				if db == nil {
+					opened, err := database.Open(config.Database, logging.For("database"))
+					if err != nil {
+						return err
+					}
+					db = opened
+				}
+				return db.Ping()
+			},
+		}}
+		deps = append(deps, notifierDependencies(config.Notifier)...)
+
+		notReady := map[string]string{}
+		for _, dep := range deps {
+			if err := dep.check(); err != nil {
+				notReady[dep.name] = err.Error()
+			}
+		}
+
+		health.update(notReady)
+
+		if len(notReady) == 0 {
+			logger.Info("all dependencies ready")
+			return db, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			if db != nil {
+				db.Close()
+			}
+			return nil, fmt.Errorf("waitForDependencies: deadline exceeded, still not ready: %v", notReady)
+		}
+
+		logger.WithField("notReady", notReady).Warnf("dependencies not ready, retrying in %s", interval)
+		time.Sleep(interval)
+		interval *= backoffFactor
+		if interval > backoffCap {
+			interval = backoffCap
+		}
+	}
+}
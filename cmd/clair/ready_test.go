@@ -0,0 +1,83 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coreos/clair/ext/notification"
+)
+
+func TestReadinessHandlerNotReady(t *testing.T) {
+	h := newReadinessHandler()
+	h.update(map[string]string{"database": "connection refused"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, false, body["ready"])
+	assert.Contains(t, body["notReady"], "database")
+}
+
+func TestReadinessHandlerReady(t *testing.T) {
+	h := newReadinessHandler()
+	h.update(map[string]string{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, true, body["ready"])
+}
+
+func TestNotifierDependenciesReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	deps := notifierDependencies(&notification.Config{Params: map[string]interface{}{"http": srv.URL}})
+	require.Len(t, deps, 1)
+	assert.NoError(t, deps[0].check())
+}
+
+func TestNotifierDependenciesNotReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	deps := notifierDependencies(&notification.Config{Params: map[string]interface{}{"http": srv.URL}})
+	require.Len(t, deps, 1)
+	assert.Error(t, deps[0].check())
+}
+
+func TestNotifierDependenciesNoEndpointConfigured(t *testing.T) {
+	assert.Empty(t, notifierDependencies(&notification.Config{}))
+	assert.Empty(t, notifierDependencies(nil))
+}
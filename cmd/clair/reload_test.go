@@ -0,0 +1,180 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coreos/clair"
+	"github.com/coreos/clair/ext/notification"
+	"github.com/coreos/clair/pkg/tlsreload"
+)
+
+type fakeUpdater struct {
+	config clair.UpdaterConfig
+}
+
+func (f *fakeUpdater) Reconfigure(cfg clair.UpdaterConfig) { f.config = cfg }
+
+type fakeNotifier struct {
+	config notification.Config
+}
+
+func (f *fakeNotifier) Reconfigure(cfg notification.Config) { f.config = cfg }
+
+const configTemplate = `
+clair:
+  database:
+    type: pgsql
+    options:
+      source: host=db
+      paginationkey: aCcl-m0zOHQ6OaYG6pr7aM1PHo-QQLLSX6g-w8gqwnM=
+  updater:
+    interval: %s
+  notifier:
+    attempts: %d
+    renotifyinterval: 2h
+  api:
+    port: 6060
+    healthport: 6061
+    timeout: 900s
+`
+
+func writeTestConfig(t *testing.T, path, interval string, attempts int) {
+	t.Helper()
+	content := fmt.Sprintf(configTemplate, interval, attempts)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestConfigWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clair.yaml")
+
+	writeTestConfig(t, path, "1h", 5)
+
+	clairConfig = nil
+	defer func() { clairConfig = nil }()
+
+	initial, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.NoError(t, initial.Validate())
+
+	updater := &fakeUpdater{}
+	notifier := &fakeNotifier{}
+	watcher := NewConfigWatcher(path, initial)
+	watcher.Updater = updater
+	watcher.Notifier = notifier
+
+	writeTestConfig(t, path, "30m", 9)
+	watcher.reload()
+
+	assert.Equal(t, 30*time.Minute, updater.config.Interval)
+	assert.Equal(t, 9, notifier.config.Attempts)
+}
+
+// writeSelfSignedCert (re-)writes a throwaway self-signed cert/key pair at
+// certPath/keyPath, with commonName baked into the subject so a test can
+// tell which generation of the cert a Keypair loaded.
+func writeSelfSignedCert(t *testing.T, certPath, keyPath, commonName string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+}
+
+func TestConfigWatcherReloadsTLSEvenWhenPathUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clair.yaml")
+
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	writeSelfSignedCert(t, certPath, keyPath, "first")
+
+	// The reloaded file must name the same cert/key paths, since reload
+	// takes them from the new configuration.
+	content := fmt.Sprintf(configTemplate, "1h", 5) + fmt.Sprintf("    certfile: %s\n    keyfile: %s\n", certPath, keyPath)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	clairConfig = nil
+	defer func() { clairConfig = nil }()
+
+	initial, err := LoadConfig(path)
+	require.NoError(t, err)
+	initial.API.CertFile = certPath
+	initial.API.KeyFile = keyPath
+
+	keypair, err := tlsreload.New(certPath, keyPath)
+	require.NoError(t, err)
+
+	watcher := NewConfigWatcher(path, initial)
+	watcher.TLS = keypair
+
+	// Re-key the same path in place, the way certbot/cert-manager would --
+	// the path string never changes, only the content on disk.
+	writeSelfSignedCert(t, certPath, keyPath, "second")
+	watcher.reload()
+
+	cert, err := keypair.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "second", leaf.Subject.CommonName)
+}
+
+func TestConfigWatcherReloadKeepsUnchangedConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clair.yaml")
+	writeTestConfig(t, path, "1h", 5)
+
+	clairConfig = nil
+	defer func() { clairConfig = nil }()
+
+	initial, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	watcher := NewConfigWatcher(path, initial)
+	watcher.reload()
+
+	assert.Equal(t, "pgsql", watcher.config.Database.Type)
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-systemd/journal"
+	log "github.com/sirupsen/logrus"
+)
+
+// journaldHook forwards logrus entries to the systemd journal instead of a
+// regular file descriptor.
+type journaldHook struct{}
+
+func newJournaldHook() (log.Hook, error) {
+	if !journal.Enabled() {
+		return nil, errJournaldUnavailable
+	}
+	return &journaldHook{}, nil
+}
+
+// Levels implements logrus.Hook.
+func (h *journaldHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (h *journaldHook) Fire(entry *log.Entry) error {
+	return journal.Send(entry.Message, journalPriority(entry.Level), fieldsFor(entry))
+}
+
+func journalPriority(level log.Level) journal.Priority {
+	switch level {
+	case log.PanicLevel, log.FatalLevel:
+		return journal.PriEmerg
+	case log.ErrorLevel:
+		return journal.PriErr
+	case log.WarnLevel:
+		return journal.PriWarning
+	case log.InfoLevel:
+		return journal.PriInfo
+	default:
+		return journal.PriDebug
+	}
+}
+
+func fieldsFor(entry *log.Entry) map[string]string {
+	fields := make(map[string]string, len(entry.Data))
+	for k, v := range entry.Data {
+		fields[k] = toString(v)
+	}
+	return fields
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// isRunningUnderSystemd reports whether the current process was started by
+// PID 1 (systemd), which is used to auto-select journald-friendly formatting
+// when --log-format is not explicitly set.
+func isRunningUnderSystemd() bool {
+	return os.Getppid() == 1 && journal.Enabled()
+}
+
+var errJournaldUnavailable = journaldUnavailableError{}
+
+type journaldUnavailableError struct{}
+
+func (journaldUnavailableError) Error() string {
+	return "logging: journald is not available on this host"
+}
@@ -0,0 +1,204 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging configures Clair's structured logging: output
+// destination, line format, and per-package verbosity overrides. Other
+// packages obtain a *logrus.Logger scoped to their name via For, rather than
+// writing to the standard logrus singleton directly.
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config controls how the logging subsystem is initialized. It is typically
+// populated from the "log" section of Clair's configuration file.
+type Config struct {
+	// Level is the default logrus level name (e.g. "info", "debug") applied
+	// to every package that has no more specific entry in PkgLevels.
+	Level string
+	// Output selects where log lines are written: "stdout", "stderr",
+	// "file:<path>", or "journald". Defaults to "stderr".
+	Output string
+	// Format selects the line format: "text", "json", or "pretty". Defaults
+	// to "text", unless Clair detects it is running as a systemd service,
+	// in which case it defaults to "journald" formatting.
+	Format string
+	// PkgLevels overrides the level for individual packages, using the
+	// syntax "pkg1=DEBUG,pkg2=WARN".
+	PkgLevels string
+}
+
+// manager owns the shared output/formatter and the parsed per-package level
+// overrides used to build package-scoped loggers.
+type manager struct {
+	mu        sync.Mutex
+	out       *os.File
+	hook      log.Hook
+	formatter log.Formatter
+	level     log.Level
+	overrides map[string]log.Level
+	loggers   map[string]*log.Logger
+}
+
+var active = &manager{
+	out:       os.Stderr,
+	formatter: &log.TextFormatter{},
+	level:     log.InfoLevel,
+	overrides: map[string]log.Level{},
+	loggers:   map[string]*log.Logger{},
+}
+
+// Configure (re)initializes the global logging subsystem from cfg. Call it
+// once during startup, before any package requests a logger via For.
+func Configure(cfg Config) error {
+	level := log.InfoLevel
+	if cfg.Level != "" {
+		parsed, err := log.ParseLevel(cfg.Level)
+		if err != nil {
+			return fmt.Errorf("logging: invalid level %q: %v", cfg.Level, err)
+		}
+		level = parsed
+	}
+
+	overrides, err := parsePkgLevels(cfg.PkgLevels)
+	if err != nil {
+		return err
+	}
+
+	format := cfg.Format
+	if format == "" {
+		if isRunningUnderSystemd() {
+			format = "journald"
+		} else {
+			format = "text"
+		}
+	}
+
+	var formatter log.Formatter
+	switch format {
+	case "text":
+		formatter = &log.TextFormatter{}
+	case "json":
+		formatter = &log.JSONFormatter{}
+	case "pretty":
+		formatter = &PrettyFormatter{}
+	case "journald":
+		formatter = &log.TextFormatter{DisableTimestamp: true}
+	default:
+		return fmt.Errorf("logging: unknown format %q", format)
+	}
+
+	var out *os.File
+	var hook log.Hook
+	switch {
+	case cfg.Output == "" || cfg.Output == "stderr":
+		out = os.Stderr
+	case cfg.Output == "stdout":
+		out = os.Stdout
+	case cfg.Output == "journald":
+		if hook, err = newJournaldHook(); err != nil {
+			return err
+		}
+		out = nil
+	case strings.HasPrefix(cfg.Output, "file:"):
+		path := strings.TrimPrefix(cfg.Output, "file:")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("logging: could not open %q: %v", path, err)
+		}
+		out = f
+	default:
+		return fmt.Errorf("logging: unknown output %q", cfg.Output)
+	}
+
+	active.mu.Lock()
+	defer active.mu.Unlock()
+	active.level = level
+	active.overrides = overrides
+	active.formatter = formatter
+	active.out = out
+	active.hook = hook
+	active.loggers = map[string]*log.Logger{}
+	return nil
+}
+
+// For returns the *logrus.Logger scoped to pkg, honoring any per-package
+// level override. The zero value of pkg ("") refers to the default/global
+// logger.
+func For(pkg string) *log.Logger {
+	active.mu.Lock()
+	defer active.mu.Unlock()
+
+	if logger, ok := active.loggers[pkg]; ok {
+		return logger
+	}
+
+	level := active.level
+	if override, ok := active.overrides[pkg]; ok {
+		level = override
+	}
+
+	logger := log.New()
+	logger.Level = level
+	logger.Formatter = active.formatter
+	switch {
+	case active.out != nil:
+		logger.Out = active.out
+	case active.hook != nil:
+		// A hook (e.g. journald) is the only destination; discard the
+		// default stderr output so entries aren't written twice.
+		logger.Out = ioutil.Discard
+	}
+	if active.hook != nil {
+		logger.Hooks.Add(active.hook)
+	}
+
+	active.loggers[pkg] = logger
+	return logger
+}
+
+// parsePkgLevels parses the "pkg1=DEBUG,pkg2=WARN" syntax used by both the
+// CLAIR_LOG_PKG_LEVELS environment variable and the log.pkglevels config key.
+func parsePkgLevels(s string) (map[string]log.Level, error) {
+	overrides := map[string]log.Level{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return overrides, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("logging: invalid pkg level entry %q, want pkg=LEVEL", entry)
+		}
+		pkg, levelName := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		level, err := log.ParseLevel(levelName)
+		if err != nil {
+			return nil, fmt.Errorf("logging: invalid level for package %q: %v", pkg, err)
+		}
+		overrides[pkg] = level
+	}
+	return overrides, nil
+}
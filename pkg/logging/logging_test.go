@@ -0,0 +1,84 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePkgLevels(t *testing.T) {
+	overrides, err := parsePkgLevels("updater=DEBUG, api=warn")
+	require.NoError(t, err)
+	assert.Equal(t, log.DebugLevel, overrides["updater"])
+	assert.Equal(t, log.WarnLevel, overrides["api"])
+}
+
+func TestParsePkgLevelsEmpty(t *testing.T) {
+	overrides, err := parsePkgLevels("")
+	require.NoError(t, err)
+	assert.Empty(t, overrides)
+}
+
+func TestParsePkgLevelsInvalidEntry(t *testing.T) {
+	_, err := parsePkgLevels("updater")
+	assert.Error(t, err)
+}
+
+func TestParsePkgLevelsInvalidLevel(t *testing.T) {
+	_, err := parsePkgLevels("updater=NOTALEVEL")
+	assert.Error(t, err)
+}
+
+func TestConfigurePerPackageLevel(t *testing.T) {
+	require.NoError(t, Configure(Config{
+		Level:     "info",
+		Output:    "stderr",
+		Format:    "text",
+		PkgLevels: "updater=DEBUG",
+	}))
+
+	assert.Equal(t, log.DebugLevel, For("updater").Level)
+	assert.Equal(t, log.InfoLevel, For("api").Level)
+}
+
+func TestConfigureFileOutput(t *testing.T) {
+	f, err := ioutil.TempFile("", "clair-log")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, Configure(Config{Level: "info", Output: "file:" + f.Name(), Format: "json"}))
+
+	logger := For("database")
+	logger.Info("hello")
+
+	content, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "hello")
+}
+
+func TestConfigureUnknownFormat(t *testing.T) {
+	err := Configure(Config{Level: "info", Output: "stderr", Format: "bogus"})
+	assert.Error(t, err)
+}
+
+func TestConfigureUnknownOutput(t *testing.T) {
+	err := Configure(Config{Level: "info", Output: "bogus", Format: "text"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PrettyFormatter renders log entries for humans watching a terminal:
+// "15:04:05 INFO  fetched 3 new vulnerabilities source=nvd".
+type PrettyFormatter struct{}
+
+// Format implements logrus.Formatter.
+func (f *PrettyFormatter) Format(entry *log.Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s %-5s %s", entry.Time.Format("15:04:05"), levelName(entry.Level), entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(buf, " %s=%v", k, entry.Data[k])
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+func levelName(level log.Level) string {
+	switch level {
+	case log.PanicLevel:
+		return "PANIC"
+	case log.FatalLevel:
+		return "FATAL"
+	case log.ErrorLevel:
+		return "ERROR"
+	case log.WarnLevel:
+		return "WARN"
+	case log.InfoLevel:
+		return "INFO"
+	case log.DebugLevel:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// resolveFile implements the "file" provider: secret://file//path/to/secret
+// resolves to the trimmed contents of /path/to/secret. This is the layout
+// used by orchestrators that mount secrets as files, e.g. Kubernetes
+// Secret volumes or Docker secrets under /run/secrets.
+func resolveFile(ref string) (string, error) {
+	path := ref
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
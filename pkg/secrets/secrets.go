@@ -0,0 +1,117 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets resolves secret://<provider>/<ref> references embedded in
+// configuration values, so that credentials (database passwords, pagination
+// keys, TLS key material) never need to live in the config file or the
+// container image that ships it.
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Resolver turns a provider-specific reference into the secret value it
+// names.
+type Resolver interface {
+	Resolve(ref string) (string, error)
+}
+
+// ResolverFunc adapts a plain function to the Resolver interface.
+type ResolverFunc func(ref string) (string, error)
+
+// Resolve implements Resolver.
+func (f ResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+var providers = map[string]Resolver{
+	"env":   ResolverFunc(resolveEnv),
+	"file":  ResolverFunc(resolveFile),
+	"vault": ResolverFunc(resolveVault),
+}
+
+// Register adds or replaces the resolver used for the given provider name.
+// It exists primarily so tests and alternate deployments can swap in a fake
+// resolver without touching the built-ins.
+func Register(provider string, resolver Resolver) {
+	providers[provider] = resolver
+}
+
+// secretPattern matches secret://<provider>/<ref> references. ref stops at
+// the next '&' or whitespace so the pattern can be embedded inside a larger
+// value such as a database DSN's query string.
+var secretPattern = regexp.MustCompile(`secret://([a-zA-Z0-9_]+)/([^&\s]+)`)
+
+// pathProviders is the subset of providers whose ref is itself a filesystem
+// path that some later file read will open, rather than the secret content
+// that should end up in the value directly. "file" and "vault" return
+// content, so they aren't safe to use with ExpandPath.
+var pathProviders = map[string]bool{
+	"env": true,
+}
+
+// Expand replaces every secret://<provider>/<ref> reference in value with
+// the secret it names. Values with no reference are returned unchanged.
+func Expand(value string) (string, error) {
+	return expand(value, nil)
+}
+
+// ExpandPath is like Expand, but rejects references to providers (such as
+// "file" or "vault") whose resolved value is secret *content*, not a path.
+// Use it for config fields that are later passed to something like
+// tls.LoadX509KeyPair, which expects a filesystem path.
+func ExpandPath(value string) (string, error) {
+	return expand(value, pathProviders)
+}
+
+// expand does the work for Expand/ExpandPath. If allowed is non-nil, only
+// providers present in it may be used; any other provider in value is
+// rejected with a descriptive error.
+func expand(value string, allowed map[string]bool) (string, error) {
+	var resolveErr error
+
+	result := secretPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+
+		groups := secretPattern.FindStringSubmatch(match)
+		provider, ref := groups[1], groups[2]
+
+		if allowed != nil && !allowed[provider] {
+			resolveErr = fmt.Errorf("secrets: provider %q in %q yields secret content, not a path; use a path-yielding provider such as env", provider, match)
+			return match
+		}
+
+		resolver, ok := providers[provider]
+		if !ok {
+			resolveErr = fmt.Errorf("secrets: unknown provider %q in %q", provider, match)
+			return match
+		}
+
+		resolved, err := resolver.Resolve(ref)
+		if err != nil {
+			resolveErr = fmt.Errorf("secrets: resolving %q: %v", match, err)
+			return match
+		}
+		return resolved
+	})
+
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return result, nil
+}
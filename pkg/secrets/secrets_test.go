@@ -0,0 +1,90 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandNoReference(t *testing.T) {
+	value, err := Expand("postgresql://clair@db/clair?sslmode=disable")
+	require.NoError(t, err)
+	assert.Equal(t, "postgresql://clair@db/clair?sslmode=disable", value)
+}
+
+func TestExpandEnvProvider(t *testing.T) {
+	require.NoError(t, os.Setenv("CLAIR_TEST_SECRET", "s3kr1t"))
+	defer os.Unsetenv("CLAIR_TEST_SECRET")
+
+	value, err := Expand("postgresql://clair@db/clair?password=secret://env/CLAIR_TEST_SECRET&sslmode=disable")
+	require.NoError(t, err)
+	assert.Equal(t, "postgresql://clair@db/clair?password=s3kr1t&sslmode=disable", value)
+}
+
+func TestExpandEnvProviderMissing(t *testing.T) {
+	_, err := Expand("secret://env/CLAIR_TEST_SECRET_NOT_SET")
+	assert.Error(t, err)
+}
+
+func TestExpandFileProvider(t *testing.T) {
+	f, err := ioutil.TempFile("", "clair-secret")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("  from-file  \n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	value, err := Expand("secret://file" + f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", value)
+}
+
+func TestExpandUnknownProvider(t *testing.T) {
+	_, err := Expand("secret://bogus/ref")
+	assert.Error(t, err)
+}
+
+func TestExpandPathRejectsContentProviders(t *testing.T) {
+	_, err := ExpandPath("secret://file/etc/clair/tls.crt")
+	assert.Error(t, err)
+
+	_, err = ExpandPath("secret://vault/secret/clair#cert")
+	assert.Error(t, err)
+}
+
+func TestExpandPathAllowsEnvProvider(t *testing.T) {
+	require.NoError(t, os.Setenv("CLAIR_TEST_CERT_PATH", "/etc/clair/tls.crt"))
+	defer os.Unsetenv("CLAIR_TEST_CERT_PATH")
+
+	value, err := ExpandPath("secret://env/CLAIR_TEST_CERT_PATH")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/clair/tls.crt", value)
+}
+
+func TestRegister(t *testing.T) {
+	Register("fake", ResolverFunc(func(ref string) (string, error) {
+		return "fake:" + ref, nil
+	}))
+	defer delete(providers, "fake")
+
+	value, err := Expand("secret://fake/thing")
+	require.NoError(t, err)
+	assert.Equal(t, "fake:thing", value)
+}
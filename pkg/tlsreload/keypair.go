@@ -0,0 +1,61 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlsreload provides a TLS certificate that can be swapped out
+// while a listener is running, so a cert renewal doesn't require a process
+// restart.
+package tlsreload
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// Keypair holds the certificate currently served by a listener. Its
+// GetCertificate method is meant to be assigned to tls.Config.GetCertificate
+// so every new handshake picks up the latest Reload.
+type Keypair struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// New loads certFile/keyFile and returns a Keypair ready to serve them.
+func New(certFile, keyFile string) (*Keypair, error) {
+	k := &Keypair{}
+	if err := k.Reload(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload reads and parses a new certificate/key pair, atomically replacing
+// the one currently served.
+func (k *Keypair) Reload(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.cert = &cert
+	k.mu.Unlock()
+	return nil
+}
+
+// GetCertificate implements the tls.Config.GetCertificate hook.
+func (k *Keypair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.cert, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 clair authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsreload
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert generates a throwaway self-signed cert/key pair under dir,
+// with commonName baked into the certificate's subject so tests can tell
+// two generated certs apart.
+func writeTestCert(t *testing.T, dir, commonName string) (certPath, keyPath string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certPath = filepath.Join(dir, commonName+"-cert.pem")
+	keyPath = filepath.Join(dir, commonName+"-key.pem")
+
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	return certPath, keyPath
+}
+
+func TestNewAndGetCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeTestCert(t, dir, "first")
+
+	kp, err := New(certPath, keyPath)
+	require.NoError(t, err)
+
+	cert, err := kp.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "first", leaf.Subject.CommonName)
+}
+
+func TestReloadSwapsCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	firstCert, firstKey := writeTestCert(t, dir, "first")
+	secondCert, secondKey := writeTestCert(t, dir, "second")
+
+	kp, err := New(firstCert, firstKey)
+	require.NoError(t, err)
+
+	before, err := kp.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	beforeLeaf, err := x509.ParseCertificate(before.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "first", beforeLeaf.Subject.CommonName)
+
+	require.NoError(t, kp.Reload(secondCert, secondKey))
+
+	after, err := kp.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	afterLeaf, err := x509.ParseCertificate(after.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "second", afterLeaf.Subject.CommonName)
+}
+
+func TestReloadInvalidPathReturnsError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeTestCert(t, dir, "first")
+	kp, err := New(certPath, keyPath)
+	require.NoError(t, err)
+
+	require.Error(t, kp.Reload(filepath.Join(dir, "missing-cert.pem"), keyPath))
+}